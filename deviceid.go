@@ -4,18 +4,78 @@
 package deviceid
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 // DefaultIDFileName is the name of the file where the device ID is stored
 const DefaultIDFileName = ".device_id"
 
+// factsFileSuffix names the JSON sidecar file that stores the per-fact
+// hashes used for fuzzy verification, relative to the device ID file.
+const factsFileSuffix = ".facts.json"
+
+// envelopeVersionV1 is the version tag of the signed device ID file
+// format: "v1:<deviceID>:<nonce>:<hmac>".
+const envelopeVersionV1 = "v1"
+
+// DefaultCollectTimeout bounds how long a single IdentitySource is given
+// to collect its fact before it is treated as unavailable.
+const DefaultCollectTimeout = 3 * time.Second
+
+// ErrTampered is returned when a signed device ID file's HMAC does not
+// match its contents, indicating the file was hand-edited or copied from
+// another host rather than simply missing.
+var ErrTampered = errors.New("deviceid: device ID file failed signature verification")
+
+// ErrNoSources is returned when no hardware fact could be collected at
+// all because every source (configured or built-in default) failed.
+var ErrNoSources = errors.New("deviceid: no hardware fact sources available")
+
+// ErrCollectionTimeout is recorded (wrapped) in a source's
+// LastCollectionDiagnostics entry when it fails to collect its fact within
+// Config.CollectTimeout; the source is then skipped.
+var ErrCollectionTimeout = errors.New("deviceid: hardware fact collection timed out")
+
+// ErrSourceUnavailable reports that a specific IdentitySource failed to
+// collect its fact, along with the underlying cause.
+type ErrSourceUnavailable struct {
+	Name string
+	Err  error
+}
+
+func (e *ErrSourceUnavailable) Error() string {
+	return fmt.Sprintf("deviceid: source %q unavailable: %v", e.Name, e.Err)
+}
+
+func (e *ErrSourceUnavailable) Unwrap() error { return e.Err }
+
+// Hardware facts collected into the composite device ID. Each is hashed
+// independently so VerifyDeviceIDFuzzy can tell which ones drifted.
+const (
+	factSystemUUID = "system_uuid"
+	factDiskSerial = "disk_serial"
+	factCPUModel   = "cpu_model"
+	factMACAddress = "mac_address"
+)
+
 // Config holds the configuration for device ID management
 type Config struct {
 	// Directory where the device ID file will be stored
@@ -24,11 +84,51 @@ type Config struct {
 	// Name of the device ID file
 	// If empty, defaults to .device_id
 	IDFileName string
+	// Sources collects the hardware facts that make up the device ID.
+	// If empty, the OS-appropriate built-in sources are used.
+	Sources []IdentitySource
+	// Namespace, if set, scopes this Manager to a single tenant: the
+	// VerifyDeviceID flow transparently reads/writes the namespaced ID
+	// derived from the root device ID (see DeriveNamespacedID) instead of
+	// the root ID itself.
+	Namespace string
+	// CollectTimeout bounds how long a single source is given to collect
+	// its fact. If zero, DefaultCollectTimeout is used.
+	CollectTimeout time.Duration
+	// SigningKeySource, if set, overrides the hardware fact source used to
+	// derive the HMAC signing key (see deriveSigningKey) instead of the
+	// built-in board serial source. Tests can set this to a StaticSource
+	// for determinism; production code should normally leave it unset.
+	SigningKeySource IdentitySource
+}
+
+// IdentitySource collects a single piece of system-specific information
+// used to derive a device ID. Implementing this interface lets callers
+// plug in their own identity facts (e.g. a TPM endorsement key or cloud
+// instance metadata) without forking the package.
+type IdentitySource interface {
+	// Name identifies the fact this source collects, used as its key in
+	// the facts sidecar and in MatchResult.
+	Name() string
+	// Collect returns the raw value of the fact. ctx governs cancellation
+	// of any underlying command or I/O.
+	Collect(ctx context.Context) ([]byte, error)
 }
 
 // Manager handles device ID operations
 type Manager struct {
 	config Config
+
+	diagnosticsMu   sync.Mutex
+	lastDiagnostics []SourceDiagnostic
+}
+
+// SourceDiagnostic records the outcome of collecting a single
+// IdentitySource's fact during the most recent collection, as reported by
+// LastCollectionDiagnostics.
+type SourceDiagnostic struct {
+	Name string
+	Err  error
 }
 
 // NewManager creates a new device ID manager with the given configuration
@@ -39,55 +139,559 @@ func NewManager(config Config) *Manager {
 	return &Manager{config: config}
 }
 
-// getSystemInfo retrieves system-specific information based on the operating system
-func getSystemInfo() (string, error) {
-	var cmd *exec.Cmd
+// hardwareFact is a single piece of system-specific information collected
+// for the composite device ID, along with the name it is hashed under.
+type hardwareFact struct {
+	name  string
+	value string
+}
+
+// MatchResult reports how a stored device ID's hardware facts compare
+// against freshly collected ones, as returned by VerifyDeviceIDFuzzy.
+type MatchResult struct {
+	// Total is the number of facts recorded in the stored sidecar.
+	Total int
+	// Matched holds the names of facts whose hash is unchanged.
+	Matched []string
+	// Drifted holds the names of facts that were collected again but
+	// no longer match the stored hash (e.g. the disk was swapped).
+	Drifted []string
+	// Missing holds the names of stored facts that could not be
+	// collected again at all (e.g. the source is no longer available).
+	Missing []string
+}
+
+// MachineIDSource reads the Linux machine ID directly from
+// /etc/machine-id, falling back to /var/lib/dbus/machine-id, instead of
+// shelling out to cat.
+type MachineIDSource struct{}
+
+// Name identifies this source as used in the facts sidecar.
+func (MachineIDSource) Name() string { return factSystemUUID }
+
+// Collect reads the machine ID file.
+func (MachineIDSource) Collect(_ context.Context) ([]byte, error) {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return bytes.TrimSpace(data), nil
+		}
+	}
+	return nil, fmt.Errorf("failed to read machine ID: no machine-id file found")
+}
+
+// DMIProductUUIDSource reads the DMI product UUID exposed by the Linux
+// kernel at /sys/class/dmi/id/product_uuid.
+type DMIProductUUIDSource struct{}
+
+// Name identifies this source as used in the facts sidecar.
+func (DMIProductUUIDSource) Name() string { return "dmi_product_uuid" }
+
+// Collect reads the DMI product UUID file.
+func (DMIProductUUIDSource) Collect(_ context.Context) ([]byte, error) {
+	data, err := os.ReadFile("/sys/class/dmi/id/product_uuid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DMI product UUID: %w", err)
+	}
+	return bytes.TrimSpace(data), nil
+}
+
+// IOPlatformUUIDSource reads the IOPlatformUUID reported by IOKit on
+// macOS via `ioreg`.
+type IOPlatformUUIDSource struct{}
+
+// Name identifies this source as used in the facts sidecar.
+func (IOPlatformUUIDSource) Name() string { return factSystemUUID }
+
+// Collect runs ioreg and extracts the IOPlatformUUID field.
+func (IOPlatformUUIDSource) Collect(ctx context.Context) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "ioreg", "-d2", "-c", "IOPlatformExpertDevice")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ioreg: %w", err)
+	}
+	value, err := parseIORegField(string(output), "IOPlatformUUID")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// parseIORegField extracts the quoted value of a `"Field" = "value"` line
+// from `ioreg` output.
+func parseIORegField(output, field string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	marker := "\"" + field + "\" = \""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, marker); idx != -1 {
+			rest := line[idx+len(marker):]
+			if end := strings.Index(rest, "\""); end != -1 {
+				return rest[:end], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("field %q not found in ioreg output", field)
+}
+
+// WMIProductUUIDSource reads the Windows product UUID reported by WMI via
+// `wmic csproduct get UUID`.
+type WMIProductUUIDSource struct{}
+
+// Name identifies this source as used in the facts sidecar.
+func (WMIProductUUIDSource) Name() string { return factSystemUUID }
+
+// Collect runs wmic and extracts the product UUID.
+func (WMIProductUUIDSource) Collect(ctx context.Context) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "wmic", "csproduct", "get", "UUID")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run wmic: %w", err)
+	}
+	value, err := firstNonHeaderLine(string(output), "UUID")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// BlockDeviceSerialSource reads the serial number of the primary disk.
+type BlockDeviceSerialSource struct{}
+
+// Name identifies this source as used in the facts sidecar.
+func (BlockDeviceSerialSource) Name() string { return factDiskSerial }
+
+// Collect reads the primary disk's serial number using the OS-appropriate
+// mechanism.
+func (BlockDeviceSerialSource) Collect(ctx context.Context) ([]byte, error) {
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command("wmic", "csproduct", "get", "UUID")
+		cmd := exec.CommandContext(ctx, "wmic", "diskdrive", "get", "SerialNumber")
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get disk serial: %w", err)
+		}
+		value, err := firstNonHeaderLine(string(output), "SerialNumber")
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value), nil
 	case "darwin":
-		cmd = exec.Command("ioreg", "-d2", "-c", "IOPlatformExpertDevice")
+		cmd := exec.CommandContext(ctx, "diskutil", "info", "/")
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get disk serial: %w", err)
+		}
+		value, err := parseDiskutilField(string(output), "Serial Number")
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value), nil
 	default: // Linux
-		cmd = exec.Command("cat", "/etc/machine-id")
+		value, err := linuxPrimaryDiskSerial()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value), nil
 	}
+}
 
-	output, err := cmd.Output()
+// boardSerialSource reads the motherboard/baseboard serial number. It is
+// deliberately not part of defaultIdentitySources or combineFacts: its sole
+// purpose is to supply deriveSigningKey with a hardware fact that plays no
+// part in the device ID itself.
+type boardSerialSource struct{}
+
+// Name identifies this source for diagnostics.
+func (boardSerialSource) Name() string { return "board_serial" }
+
+// Collect reads the board serial number using the OS-appropriate mechanism.
+func (boardSerialSource) Collect(ctx context.Context) ([]byte, error) {
+	switch runtime.GOOS {
+	case "windows":
+		cmd := exec.CommandContext(ctx, "wmic", "baseboard", "get", "SerialNumber")
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get board serial: %w", err)
+		}
+		value, err := firstNonHeaderLine(string(output), "SerialNumber")
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value), nil
+	case "darwin":
+		cmd := exec.CommandContext(ctx, "ioreg", "-d2", "-c", "IOPlatformExpertDevice")
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get board serial: %w", err)
+		}
+		value, err := parseIORegField(string(output), "IOPlatformSerialNumber")
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value), nil
+	default: // Linux
+		data, err := os.ReadFile("/sys/class/dmi/id/board_serial")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read board serial: %w", err)
+		}
+		return bytes.TrimSpace(data), nil
+	}
+}
+
+// linuxPrimaryDiskSerial reads the serial of the first block device under
+// /sys/block that looks like a real disk (sd*, nvme*, vd*).
+func linuxPrimaryDiskSerial() (string, error) {
+	entries, err := os.ReadDir("/sys/block")
 	if err != nil {
-		return "", fmt.Errorf("failed to get system info: %w", err)
+		return "", fmt.Errorf("failed to list block devices: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "sd") || strings.HasPrefix(name, "nvme") || strings.HasPrefix(name, "vd") {
+			names = append(names, name)
+		}
 	}
-	return string(output), nil
+	sort.Strings(names)
+
+	for _, name := range names {
+		serial, err := os.ReadFile(filepath.Join("/sys/block", name, "device/serial"))
+		if err != nil {
+			continue
+		}
+		if value := strings.TrimSpace(string(serial)); value != "" {
+			return value, nil
+		}
+	}
+
+	return "", fmt.Errorf("no block device serial found")
+}
+
+// parseDiskutilField extracts the value of a "Field Name:   value" line
+// from `diskutil info` output.
+func parseDiskutilField(output, field string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, field+":"); idx != -1 {
+			value := strings.TrimSpace(line[idx+len(field)+1:])
+			if value != "" {
+				return value, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("field %q not found in diskutil output", field)
+}
+
+// firstNonHeaderLine returns the first non-empty line in a wmic `get`
+// output that isn't the column header itself.
+func firstNonHeaderLine(output, header string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line == header {
+			continue
+		}
+		return line, nil
+	}
+	return "", fmt.Errorf("no value found in wmic output")
+}
+
+// cpuModelSource retrieves the CPU model/family string.
+type cpuModelSource struct{}
+
+func (cpuModelSource) Name() string { return factCPUModel }
+
+func (cpuModelSource) Collect(ctx context.Context) ([]byte, error) {
+	switch runtime.GOOS {
+	case "windows":
+		cmd := exec.CommandContext(ctx, "wmic", "cpu", "get", "Name")
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get CPU model: %w", err)
+		}
+		value, err := firstNonHeaderLine(string(output), "Name")
+		if err != nil {
+			return nil, err
+		}
+		return []byte(value), nil
+	case "darwin":
+		cmd := exec.CommandContext(ctx, "sysctl", "-n", "machdep.cpu.brand_string")
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get CPU model: %w", err)
+		}
+		return bytes.TrimSpace(output), nil
+	default: // Linux
+		file, err := os.Open("/proc/cpuinfo")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open /proc/cpuinfo: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "model name") {
+				if idx := strings.Index(line, ":"); idx != -1 {
+					return []byte(strings.TrimSpace(line[idx+1:])), nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("model name not found in /proc/cpuinfo")
+	}
+}
+
+// macAddressSource returns the hardware address of the first non-loopback
+// network interface that has one.
+type macAddressSource struct{}
+
+func (macAddressSource) Name() string { return factMACAddress }
+
+func (macAddressSource) Collect(_ context.Context) ([]byte, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if len(iface.HardwareAddr) == 0 {
+			continue
+		}
+		return []byte(iface.HardwareAddr.String()), nil
+	}
+
+	return nil, fmt.Errorf("no non-loopback interface with a MAC address found")
+}
+
+// StaticSource is an IdentitySource with a fixed name and value, useful
+// for injecting deterministic facts in tests.
+type StaticSource struct {
+	SourceName string
+	Value      []byte
+}
+
+// Name returns the configured source name.
+func (s StaticSource) Name() string { return s.SourceName }
+
+// Collect returns the configured value.
+func (s StaticSource) Collect(_ context.Context) ([]byte, error) {
+	return s.Value, nil
+}
+
+// defaultIdentitySources returns the OS-appropriate built-in sources used
+// when a Manager is not configured with its own Config.Sources.
+func defaultIdentitySources() []IdentitySource {
+	switch runtime.GOOS {
+	case "windows":
+		return []IdentitySource{WMIProductUUIDSource{}, BlockDeviceSerialSource{}, cpuModelSource{}, macAddressSource{}}
+	case "darwin":
+		return []IdentitySource{IOPlatformUUIDSource{}, BlockDeviceSerialSource{}, cpuModelSource{}, macAddressSource{}}
+	default: // Linux
+		return []IdentitySource{MachineIDSource{}, DMIProductUUIDSource{}, BlockDeviceSerialSource{}, cpuModelSource{}, macAddressSource{}}
+	}
+}
+
+// identitySources returns the sources this Manager collects facts from,
+// falling back to defaultIdentitySources when none were configured.
+func (m *Manager) identitySources() []IdentitySource {
+	if len(m.config.Sources) > 0 {
+		return m.config.Sources
+	}
+	return defaultIdentitySources()
+}
+
+// collectTimeout returns the configured per-source collection timeout, or
+// DefaultCollectTimeout if none was set.
+func (m *Manager) collectTimeout() time.Duration {
+	if m.config.CollectTimeout > 0 {
+		return m.config.CollectTimeout
+	}
+	return DefaultCollectTimeout
+}
+
+// collectHardwareFacts gathers the independent hardware facts that make up
+// the composite device ID, bounding each source by Config.CollectTimeout.
+func (m *Manager) collectHardwareFacts() []hardwareFact {
+	facts, _ := m.collectHardwareFactsContext(context.Background())
+	return facts
+}
+
+// collectHardwareFactsContext gathers the independent hardware facts that
+// make up the composite device ID, running each source's Collect under
+// its own collectTimeout-bounded context. A source that fails or times
+// out is skipped rather than failing the whole operation, so the ID
+// degrades gracefully instead of becoming unobtainable when a single
+// source is missing; its outcome is recorded for LastCollectionDiagnostics.
+// Returns ErrNoSources if no fact could be collected at all.
+func (m *Manager) collectHardwareFactsContext(ctx context.Context) ([]hardwareFact, error) {
+	sources := m.identitySources()
+
+	facts := make([]hardwareFact, 0, len(sources))
+	diagnostics := make([]SourceDiagnostic, 0, len(sources))
+
+	for _, src := range sources {
+		factCtx, cancel := context.WithTimeout(ctx, m.collectTimeout())
+		value, err := src.Collect(factCtx)
+		timedOut := factCtx.Err() == context.DeadlineExceeded
+		cancel()
+
+		switch {
+		case err != nil && timedOut:
+			err = fmt.Errorf("%w: source %q: %v", ErrCollectionTimeout, src.Name(), err)
+		case err != nil:
+			err = &ErrSourceUnavailable{Name: src.Name(), Err: err}
+		case len(bytes.TrimSpace(value)) == 0:
+			err = &ErrSourceUnavailable{Name: src.Name(), Err: fmt.Errorf("empty value")}
+		}
+
+		diagnostics = append(diagnostics, SourceDiagnostic{Name: src.Name(), Err: err})
+		if err != nil {
+			continue
+		}
+		facts = append(facts, hardwareFact{name: src.Name(), value: string(value)})
+	}
+
+	m.diagnosticsMu.Lock()
+	m.lastDiagnostics = diagnostics
+	m.diagnosticsMu.Unlock()
+
+	if len(facts) == 0 {
+		return nil, ErrNoSources
+	}
+	return facts, nil
+}
+
+// LastCollectionDiagnostics reports the outcome of each source consulted
+// during the most recent fact collection (GenerateDeviceID,
+// GenerateDeviceIDContext, or any operation that regenerates the ID), to
+// help operators debug why a specific source failed.
+func (m *Manager) LastCollectionDiagnostics() []SourceDiagnostic {
+	m.diagnosticsMu.Lock()
+	defer m.diagnosticsMu.Unlock()
+	return append([]SourceDiagnostic(nil), m.lastDiagnostics...)
+}
+
+// combineFacts derives the composite device ID from a set of hardware
+// facts and returns the per-fact SHA256 hashes alongside it.
+func combineFacts(facts []hardwareFact) (deviceID string, factHashes map[string]string) {
+	combined := sha256.New()
+	factHashes = make(map[string]string, len(facts))
+
+	for _, f := range facts {
+		hash := sha256.Sum256([]byte(f.value))
+		factHashes[f.name] = hex.EncodeToString(hash[:])
+
+		combined.Write([]byte(f.name))
+		combined.Write([]byte{0})
+		combined.Write([]byte(f.value))
+		combined.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(combined.Sum(nil)), factHashes
 }
 
-// GenerateDeviceID creates a new device ID based on system information
+// GenerateDeviceID creates a new device ID based on a composite of
+// independent hardware facts (system UUID, primary disk serial, CPU model,
+// and MAC address), rather than a single system-info source. Collection is
+// bounded by Config.CollectTimeout per source; use GenerateDeviceIDContext
+// to additionally bind it to a caller-supplied context.
 func (m *Manager) GenerateDeviceID() (string, error) {
-	info, err := getSystemInfo()
+	return m.GenerateDeviceIDContext(context.Background())
+}
+
+// GenerateDeviceIDContext is GenerateDeviceID with collection bound to ctx
+// as well as to Config.CollectTimeout. It returns ErrNoSources if every
+// source failed or timed out.
+func (m *Manager) GenerateDeviceIDContext(ctx context.Context) (string, error) {
+	deviceID, _, err := m.generateDeviceIDFacts(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate device ID: %w", err)
 	}
+	return deviceID, nil
+}
 
-	hash := sha256.Sum256([]byte(info))
-	return hex.EncodeToString(hash[:]), nil
+// generateDeviceIDFacts collects hardware facts once and returns both the
+// composite device ID derived from them and the facts themselves, so
+// callers that also need to persist a facts sidecar don't have to collect
+// twice.
+func (m *Manager) generateDeviceIDFacts(ctx context.Context) (string, []hardwareFact, error) {
+	facts, err := m.collectHardwareFactsContext(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	deviceID, _ := combineFacts(facts)
+	return deviceID, facts, nil
 }
 
-// GetDeviceIDPath returns the full path where the device ID file should be stored
-func (m *Manager) GetDeviceIDPath() (string, error) {
-	var basePath string
+// generateAndSaveDeviceIDContext generates a new device ID and saves it
+// along with the facts sidecar, collecting hardware facts exactly once.
+func (m *Manager) generateAndSaveDeviceIDContext(ctx context.Context) (string, error) {
+	deviceID, facts, err := m.generateDeviceIDFacts(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate new device ID: %w", err)
+	}
+	if err := m.saveDeviceIDWithFacts(deviceID, facts); err != nil {
+		return "", fmt.Errorf("failed to save new device ID: %w", err)
+	}
+	return deviceID, nil
+}
+
+// generateAndSaveSignedDeviceIDContext generates a new device ID and saves
+// it as a signed envelope along with the facts sidecar, collecting
+// hardware facts exactly once.
+func (m *Manager) generateAndSaveSignedDeviceIDContext(ctx context.Context) (string, error) {
+	deviceID, facts, err := m.generateDeviceIDFacts(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate new device ID: %w", err)
+	}
+	if err := m.saveSignedDeviceIDWithFacts(deviceID, facts); err != nil {
+		return "", fmt.Errorf("failed to save new device ID: %w", err)
+	}
+	return deviceID, nil
+}
 
+// baseDir returns the directory device ID files are stored under.
+func (m *Manager) baseDir() (string, error) {
 	if m.config.StorageDir != "" {
-		basePath = m.config.StorageDir
-	} else {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("failed to get user home directory: %w", err)
-		}
-		basePath = filepath.Join(home, ".parity")
+		return m.config.StorageDir, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".parity"), nil
+}
+
+// GetDeviceIDPath returns the full path where the device ID file should be stored
+func (m *Manager) GetDeviceIDPath() (string, error) {
+	basePath, err := m.baseDir()
+	if err != nil {
+		return "", err
 	}
 
 	return filepath.Join(basePath, m.config.IDFileName), nil
 }
 
-// SaveDeviceID stores the device ID in the configured location
+// SaveDeviceID stores the device ID in the configured location. The facts
+// sidecar is populated from a fresh hardware fact collection; callers that
+// already have the facts used to produce deviceID (e.g. after generating it)
+// should use saveDeviceIDWithFacts instead to avoid collecting twice.
 func (m *Manager) SaveDeviceID(deviceID string) error {
+	return m.saveDeviceIDWithFacts(deviceID, m.collectHardwareFacts())
+}
+
+// saveDeviceIDWithFacts stores deviceID and, if facts is non-empty, a facts
+// sidecar derived from it. facts should be the same collection that
+// produced deviceID, so the sidecar can never diverge from what the ID was
+// actually built from.
+func (m *Manager) saveDeviceIDWithFacts(deviceID string, facts []hardwareFact) error {
 	if !IsValidSHA256(deviceID) {
 		return fmt.Errorf("invalid device ID format")
 	}
@@ -107,11 +711,365 @@ func (m *Manager) SaveDeviceID(deviceID string) error {
 		return fmt.Errorf("failed to write device ID: %w", err)
 	}
 
+	if len(facts) > 0 {
+		_, factHashes := combineFacts(facts)
+		if err := m.saveFactsSidecar(path, factHashes); err != nil {
+			return fmt.Errorf("failed to save device facts: %w", err)
+		}
+	}
+
 	return nil
 }
 
-// VerifyDeviceID checks for an existing device ID and generates a new one if needed
+// factsSidecarPath returns the path of the JSON sidecar file that stores
+// per-fact hashes alongside the device ID file at path.
+func factsSidecarPath(path string) string {
+	return path + factsFileSuffix
+}
+
+// factsSidecar is the on-disk JSON format of the facts sidecar file.
+type factsSidecar struct {
+	Facts map[string]string `json:"facts"`
+}
+
+// saveFactsSidecar writes the per-fact hashes used for fuzzy verification
+// to the JSON sidecar alongside the device ID file.
+func (m *Manager) saveFactsSidecar(path string, factHashes map[string]string) error {
+	data, err := json.MarshalIndent(factsSidecar{Facts: factHashes}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal device facts: %w", err)
+	}
+	return os.WriteFile(factsSidecarPath(path), data, 0o600)
+}
+
+// loadFactsSidecar reads the per-fact hashes previously stored alongside
+// the device ID file.
+func (m *Manager) loadFactsSidecar(path string) (factsSidecar, error) {
+	var sidecar factsSidecar
+	data, err := os.ReadFile(factsSidecarPath(path))
+	if err != nil {
+		return sidecar, err
+	}
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return sidecar, fmt.Errorf("failed to parse device facts: %w", err)
+	}
+	return sidecar, nil
+}
+
+// signedEnvelope is the parsed form of a "v1:<deviceID>:<nonce>:<hmac>"
+// device ID file.
+type signedEnvelope struct {
+	DeviceID string
+	Nonce    string
+	MAC      string
+}
+
+// parseEnvelope parses a signed device ID file's contents. ok is false if
+// data is not a recognized envelope (e.g. a legacy plain-hex file).
+func parseEnvelope(data string) (envelope signedEnvelope, ok bool) {
+	parts := strings.Split(strings.TrimSpace(data), ":")
+	if len(parts) != 4 || parts[0] != envelopeVersionV1 {
+		return signedEnvelope{}, false
+	}
+	return signedEnvelope{DeviceID: parts[1], Nonce: parts[2], MAC: parts[3]}, true
+}
+
+// String renders the envelope back to its on-disk "v1:..." form.
+func (e signedEnvelope) String() string {
+	return strings.Join([]string{envelopeVersionV1, e.DeviceID, e.Nonce, e.MAC}, ":")
+}
+
+// hkdfSHA256 derives length bytes of key material from secret using
+// HKDF (RFC 5869) with SHA256, binding the output to info.
+func hkdfSHA256(secret, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, make([]byte, sha256.Size))
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	var (
+		okm  []byte
+		prev []byte
+	)
+	for counter := byte(1); len(okm) < length; counter++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(prev)
+		expand.Write(info)
+		expand.Write([]byte{counter})
+		prev = expand.Sum(nil)
+		okm = append(okm, prev...)
+	}
+	return okm[:length]
+}
+
+// deriveSigningKey derives the HMAC key used to sign device ID files from
+// the board serial number, a hardware fact that is never collected by
+// identitySources and never folded into the device ID via combineFacts, so
+// that copying the ID file alone isn't enough to forge a matching signature
+// on another host. Config.SigningKeySource overrides the source, primarily
+// for tests.
+func (m *Manager) deriveSigningKey() ([]byte, error) {
+	source := m.config.SigningKeySource
+	if source == nil {
+		source = boardSerialSource{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.collectTimeout())
+	defer cancel()
+
+	value, err := source.Collect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect signing key fact: %w", err)
+	}
+	secondary := bytes.TrimSpace(value)
+	if len(secondary) == 0 {
+		return nil, fmt.Errorf("signing key fact %q was empty, cannot derive signing key", source.Name())
+	}
+
+	return hkdfSHA256(secondary, []byte("deviceid-hmac-key-v1"), sha256.Size), nil
+}
+
+// signEnvelope signs deviceID with a fresh random nonce, producing the
+// envelope to write to disk.
+func (m *Manager) signEnvelope(deviceID string) (signedEnvelope, error) {
+	key, err := m.deriveSigningKey()
+	if err != nil {
+		return signedEnvelope{}, fmt.Errorf("failed to derive signing key: %w", err)
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return signedEnvelope{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	nonceHex := hex.EncodeToString(nonce)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(deviceID))
+	mac.Write([]byte(nonceHex))
+
+	return signedEnvelope{
+		DeviceID: deviceID,
+		Nonce:    nonceHex,
+		MAC:      hex.EncodeToString(mac.Sum(nil)),
+	}, nil
+}
+
+// verifyEnvelope reports whether envelope's HMAC matches its contents
+// under the current signing key.
+func (m *Manager) verifyEnvelope(envelope signedEnvelope) (bool, error) {
+	key, err := m.deriveSigningKey()
+	if err != nil {
+		return false, fmt.Errorf("failed to derive signing key: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(envelope.DeviceID))
+	mac.Write([]byte(envelope.Nonce))
+	expected := mac.Sum(nil)
+
+	got, err := hex.DecodeString(envelope.MAC)
+	if err != nil {
+		return false, nil
+	}
+	return hmac.Equal(expected, got), nil
+}
+
+// SaveSignedDeviceID stores the device ID as a tamper-evident envelope:
+// "v1:<deviceID>:<nonce>:<hmac>", where the HMAC is computed over
+// deviceID and nonce with a key derived from a secondary hardware fact.
+// This lets VerifySignedDeviceID tell a hand-edited or copied-between-hosts
+// file apart from one that is simply missing. The facts sidecar is
+// populated from a fresh hardware fact collection; callers that already
+// have the facts used to produce deviceID should use
+// saveSignedDeviceIDWithFacts instead to avoid collecting twice.
+func (m *Manager) SaveSignedDeviceID(deviceID string) error {
+	return m.saveSignedDeviceIDWithFacts(deviceID, m.collectHardwareFacts())
+}
+
+// saveSignedDeviceIDWithFacts stores deviceID as a signed envelope and, if
+// facts is non-empty, a facts sidecar derived from it. facts should be the
+// same collection that produced deviceID, so the sidecar can never diverge
+// from what the ID was actually built from.
+func (m *Manager) saveSignedDeviceIDWithFacts(deviceID string, facts []hardwareFact) error {
+	if !IsValidSHA256(deviceID) {
+		return fmt.Errorf("invalid device ID format")
+	}
+
+	path, err := m.GetDeviceIDPath()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID path: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	envelope, err := m.signEnvelope(deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to sign device ID: %w", err)
+	}
+
+	if err := os.WriteFile(path, []byte(envelope.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write signed device ID: %w", err)
+	}
+
+	if len(facts) > 0 {
+		_, factHashes := combineFacts(facts)
+		if err := m.saveFactsSidecar(path, factHashes); err != nil {
+			return fmt.Errorf("failed to save device facts: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// VerifySignedDeviceID checks for an existing signed device ID file,
+// generating and signing a new one if none exists. A legacy plain-hex
+// file (from SaveDeviceID) is accepted and migrated to the signed
+// envelope format. A present envelope whose HMAC does not match its
+// contents is reported as ErrTampered rather than silently regenerated.
+func (m *Manager) VerifySignedDeviceID() (string, error) {
+	path, err := m.GetDeviceIDPath()
+	if err != nil {
+		return "", fmt.Errorf("failed to get device ID path: %w", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			newID, err := m.generateAndSaveSignedDeviceIDContext(context.Background())
+			if err != nil {
+				return "", err
+			}
+			return newID, nil
+		}
+		return "", fmt.Errorf("failed to read device ID: %w", err)
+	}
+
+	content := string(raw)
+	if envelope, ok := parseEnvelope(content); ok {
+		if !IsValidSHA256(envelope.DeviceID) {
+			return "", ErrTampered
+		}
+		valid, err := m.verifyEnvelope(envelope)
+		if err != nil {
+			return "", fmt.Errorf("failed to verify device ID signature: %w", err)
+		}
+		if !valid {
+			return "", ErrTampered
+		}
+		return envelope.DeviceID, nil
+	}
+
+	// Legacy plain-hex file: accept it, then migrate to the signed format.
+	storedID := content
+	if !IsValidSHA256(storedID) {
+		newID, err := m.generateAndSaveSignedDeviceIDContext(context.Background())
+		if err != nil {
+			return "", err
+		}
+		return newID, nil
+	}
+
+	if err := m.SaveSignedDeviceID(storedID); err != nil {
+		return "", fmt.Errorf("failed to migrate device ID to signed format: %w", err)
+	}
+	return storedID, nil
+}
+
+// VerifyDeviceIDFuzzy checks the stored device ID against freshly
+// collected hardware facts and accepts it as long as at least threshold
+// of the originally recorded facts still match. This distinguishes a
+// partial hardware change (e.g. a swapped disk) from a fully different
+// machine, which a plain equality check cannot express.
+func (m *Manager) VerifyDeviceIDFuzzy(threshold int) (string, MatchResult, error) {
+	path, err := m.GetDeviceIDPath()
+	if err != nil {
+		return "", MatchResult{}, fmt.Errorf("failed to get device ID path: %w", err)
+	}
+
+	storedIDBytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			newID, err := m.generateAndSaveDeviceIDContext(context.Background())
+			if err != nil {
+				return "", MatchResult{}, err
+			}
+			return newID, MatchResult{}, nil
+		}
+		return "", MatchResult{}, fmt.Errorf("failed to read device ID: %w", err)
+	}
+
+	storedID := string(storedIDBytes)
+	if !IsValidSHA256(storedID) {
+		newID, err := m.generateAndSaveDeviceIDContext(context.Background())
+		if err != nil {
+			return "", MatchResult{}, err
+		}
+		return newID, MatchResult{}, nil
+	}
+
+	sidecar, err := m.loadFactsSidecar(path)
+	if err != nil {
+		// No facts were recorded for this ID (e.g. it predates fuzzy
+		// matching); fall back to treating it as an exact match.
+		return storedID, MatchResult{}, nil
+	}
+
+	_, currentHashes := combineFacts(m.collectHardwareFacts())
+
+	result := MatchResult{Total: len(sidecar.Facts)}
+	names := make([]string, 0, len(sidecar.Facts))
+	for name := range sidecar.Facts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		storedHash := sidecar.Facts[name]
+		currentHash, collected := currentHashes[name]
+		switch {
+		case !collected:
+			result.Missing = append(result.Missing, name)
+		case currentHash == storedHash:
+			result.Matched = append(result.Matched, name)
+		default:
+			result.Drifted = append(result.Drifted, name)
+		}
+	}
+
+	if len(result.Matched) >= threshold {
+		return storedID, result, nil
+	}
+
+	return "", result, fmt.Errorf("device identity drifted: only %d of %d facts matched (threshold %d)", len(result.Matched), result.Total, threshold)
+}
+
+// VerifyDeviceID checks for an existing device ID and generates a new one
+// if needed. It understands both the legacy plain-hex file and the signed
+// envelope written by SaveSignedDeviceID; for the latter, a mismatched
+// HMAC is reported as ErrTampered rather than silently regenerated.
+//
+// If Config.Namespace is set, this transparently reads/writes that
+// tenant's namespaced ID (see DeriveNamespacedID) instead of the root ID.
+// Use VerifyDeviceIDContext to additionally bind any regeneration to a
+// caller-supplied context.
 func (m *Manager) VerifyDeviceID() (string, error) {
+	return m.VerifyDeviceIDContext(context.Background())
+}
+
+// VerifyDeviceIDContext is VerifyDeviceID with any hardware fact
+// collection needed to regenerate the ID bound to ctx as well as to
+// Config.CollectTimeout.
+func (m *Manager) VerifyDeviceIDContext(ctx context.Context) (string, error) {
+	if m.config.Namespace != "" {
+		return m.verifyNamespacedDeviceIDContext(ctx, m.config.Namespace)
+	}
+	return m.verifyRootDeviceIDContext(ctx)
+}
+
+// verifyRootDeviceIDContext implements the root-ID verification flow,
+// ignoring Config.Namespace.
+func (m *Manager) verifyRootDeviceIDContext(ctx context.Context) (string, error) {
 	path, err := m.GetDeviceIDPath()
 	if err != nil {
 		return "", fmt.Errorf("failed to get device ID path: %w", err)
@@ -122,28 +1080,36 @@ func (m *Manager) VerifyDeviceID() (string, error) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Generate new device ID if it doesn't exist
-			newID, err := m.GenerateDeviceID()
+			newID, err := m.generateAndSaveDeviceIDContext(ctx)
 			if err != nil {
-				return "", fmt.Errorf("failed to generate new device ID: %w", err)
-			}
-			if err := m.SaveDeviceID(newID); err != nil {
-				return "", fmt.Errorf("failed to save new device ID: %w", err)
+				return "", err
 			}
 			return newID, nil
 		}
 		return "", fmt.Errorf("failed to read device ID: %w", err)
 	}
 
+	if envelope, ok := parseEnvelope(string(deviceID)); ok {
+		if !IsValidSHA256(envelope.DeviceID) {
+			return "", ErrTampered
+		}
+		valid, err := m.verifyEnvelope(envelope)
+		if err != nil {
+			return "", fmt.Errorf("failed to verify device ID signature: %w", err)
+		}
+		if !valid {
+			return "", ErrTampered
+		}
+		return envelope.DeviceID, nil
+	}
+
 	// Validate the stored device ID format
 	storedID := string(deviceID)
 	if !IsValidSHA256(storedID) {
 		// If invalid format, generate a new one
-		newID, err := m.GenerateDeviceID()
+		newID, err := m.generateAndSaveDeviceIDContext(ctx)
 		if err != nil {
-			return "", fmt.Errorf("failed to generate new device ID: %w", err)
-		}
-		if err := m.SaveDeviceID(newID); err != nil {
-			return "", fmt.Errorf("failed to save new device ID: %w", err)
+			return "", err
 		}
 		return newID, nil
 	}
@@ -151,6 +1117,91 @@ func (m *Manager) VerifyDeviceID() (string, error) {
 	return storedID, nil
 }
 
+// namespaceIDPath returns the path the derived ID for namespace is
+// persisted under: <StorageDir>/namespaces/<sha256(namespace)>.
+func (m *Manager) namespaceIDPath(namespace string) (string, error) {
+	base, err := m.baseDir()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(namespace))
+	return filepath.Join(base, "namespaces", hex.EncodeToString(hash[:])), nil
+}
+
+// deriveNamespacedID computes SHA256(rootID || 0x00 || namespace), giving
+// a tenant-scoped ID that stays stable for a given (rootID, namespace)
+// pair without revealing the root ID to holders of the derived one.
+func deriveNamespacedID(rootID, namespace string) string {
+	combined := make([]byte, 0, len(rootID)+1+len(namespace))
+	combined = append(combined, rootID...)
+	combined = append(combined, 0x00)
+	combined = append(combined, namespace...)
+	hash := sha256.Sum256(combined)
+	return hex.EncodeToString(hash[:])
+}
+
+// verifyNamespacedDeviceIDContext returns the persisted namespaced ID for
+// namespace, deriving and saving it from the root device ID on first use.
+func (m *Manager) verifyNamespacedDeviceIDContext(ctx context.Context, namespace string) (string, error) {
+	path, err := m.namespaceIDPath(namespace)
+	if err != nil {
+		return "", fmt.Errorf("failed to get namespace ID path: %w", err)
+	}
+
+	stored, err := os.ReadFile(path)
+	if err == nil && IsValidSHA256(string(stored)) {
+		return string(stored), nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read namespaced device ID: %w", err)
+	}
+
+	return m.deriveAndSaveNamespacedIDContext(ctx, namespace)
+}
+
+// deriveAndSaveNamespacedIDContext derives namespace's ID from the root
+// device ID and persists it, creating the root ID first if it doesn't
+// exist yet.
+func (m *Manager) deriveAndSaveNamespacedIDContext(ctx context.Context, namespace string) (string, error) {
+	rootID, err := m.verifyRootDeviceIDContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify root device ID: %w", err)
+	}
+
+	namespacedID := deriveNamespacedID(rootID, namespace)
+
+	path, err := m.namespaceIDPath(namespace)
+	if err != nil {
+		return "", fmt.Errorf("failed to get namespace ID path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create namespace directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(namespacedID), 0o600); err != nil {
+		return "", fmt.Errorf("failed to save namespaced device ID: %w", err)
+	}
+
+	return namespacedID, nil
+}
+
+// DeriveNamespacedID returns a stable, tenant-scoped ID derived from this
+// Manager's root device ID: SHA256(rootDeviceID || 0x00 || namespace). The
+// derived ID is recomputed from the root ID on every call and written to
+// <StorageDir>/namespaces/<sha256(namespace)>; repeated calls for the same
+// namespace return the same ID as long as the root ID is unchanged. This lets an
+// application running multiple isolated workloads (per-user agents,
+// per-container workers) get stable-but-distinct IDs rooted in the same
+// hardware identity, without each workload reimplementing the derivation.
+//
+// Unlike VerifyDeviceID with Config.Namespace set, this always derives
+// from the root ID regardless of this Manager's own Namespace.
+func (m *Manager) DeriveNamespacedID(namespace string) (string, error) {
+	if namespace == "" {
+		return "", fmt.Errorf("namespace must not be empty")
+	}
+	return m.deriveAndSaveNamespacedIDContext(context.Background(), namespace)
+}
+
 // IsValidSHA256 checks if a string is a valid SHA256 hash
 func IsValidSHA256(s string) bool {
 	if len(s) != 64 {
@@ -0,0 +1,125 @@
+package deviceid
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// slowSource blocks for delay (or until ctx is cancelled, whichever comes
+// first), to exercise per-source timeout handling.
+type slowSource struct {
+	name  string
+	delay time.Duration
+}
+
+func (s slowSource) Name() string { return s.name }
+
+func (s slowSource) Collect(ctx context.Context) ([]byte, error) {
+	select {
+	case <-time.After(s.delay):
+		return []byte("slow-value"), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// failingSource always fails to collect.
+type failingSource struct {
+	name string
+}
+
+func (s failingSource) Name() string { return s.name }
+
+func (s failingSource) Collect(_ context.Context) ([]byte, error) {
+	return nil, errors.New("boom")
+}
+
+func TestCollectHardwareFactsContext_SourceTimeout(t *testing.T) {
+	m := NewManager(Config{
+		StorageDir:     t.TempDir(),
+		CollectTimeout: 20 * time.Millisecond,
+		Sources:        []IdentitySource{slowSource{name: "slow", delay: 200 * time.Millisecond}},
+	})
+
+	_, err := m.GenerateDeviceIDContext(context.Background())
+	if !errors.Is(err, ErrNoSources) {
+		t.Fatalf("GenerateDeviceIDContext with only a timed-out source: got %v, want ErrNoSources", err)
+	}
+
+	diags := m.LastCollectionDiagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("LastCollectionDiagnostics: got %d entries, want 1", len(diags))
+	}
+	if diags[0].Name != "slow" {
+		t.Errorf("LastCollectionDiagnostics[0].Name: got %q, want %q", diags[0].Name, "slow")
+	}
+	if !errors.Is(diags[0].Err, ErrCollectionTimeout) {
+		t.Errorf("LastCollectionDiagnostics[0].Err: got %v, want it to wrap ErrCollectionTimeout", diags[0].Err)
+	}
+}
+
+func TestGenerateDeviceIDContext_AllSourcesFail(t *testing.T) {
+	m := NewManager(Config{
+		StorageDir: t.TempDir(),
+		Sources: []IdentitySource{
+			failingSource{name: "a"},
+			failingSource{name: "b"},
+		},
+	})
+
+	_, err := m.GenerateDeviceIDContext(context.Background())
+	if !errors.Is(err, ErrNoSources) {
+		t.Fatalf("GenerateDeviceIDContext with all sources failing: got %v, want ErrNoSources", err)
+	}
+
+	diags := m.LastCollectionDiagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("LastCollectionDiagnostics: got %d entries, want 2", len(diags))
+	}
+	for _, d := range diags {
+		var unavailable *ErrSourceUnavailable
+		if !errors.As(d.Err, &unavailable) {
+			t.Errorf("LastCollectionDiagnostics[%q].Err: got %v, want *ErrSourceUnavailable", d.Name, d.Err)
+		}
+	}
+}
+
+func TestCollectHardwareFactsContext_PartialFailureDegradesGracefully(t *testing.T) {
+	m := NewManager(Config{
+		StorageDir: t.TempDir(),
+		Sources: []IdentitySource{
+			StaticSource{SourceName: "good", Value: []byte("v")},
+			failingSource{name: "bad"},
+		},
+	})
+
+	id, err := m.GenerateDeviceIDContext(context.Background())
+	if err != nil {
+		t.Fatalf("GenerateDeviceIDContext with one good and one failing source: %v", err)
+	}
+	if !IsValidSHA256(id) {
+		t.Fatalf("GenerateDeviceIDContext returned an invalid device ID: %q", id)
+	}
+
+	diags := m.LastCollectionDiagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("LastCollectionDiagnostics: got %d entries, want 2", len(diags))
+	}
+	for _, d := range diags {
+		switch d.Name {
+		case "good":
+			if d.Err != nil {
+				t.Errorf("LastCollectionDiagnostics[%q].Err: got %v, want nil", d.Name, d.Err)
+			}
+		case "bad":
+			var unavailable *ErrSourceUnavailable
+			if !errors.As(d.Err, &unavailable) {
+				t.Errorf("LastCollectionDiagnostics[%q].Err: got %v, want *ErrSourceUnavailable", d.Name, d.Err)
+			}
+		default:
+			t.Errorf("unexpected diagnostic for source %q", d.Name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package deviceid
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeriveNamespacedID_StableAcrossCalls(t *testing.T) {
+	m := NewManager(testConfig(t,
+		StaticSource{SourceName: factSystemUUID, Value: []byte("uuid-1")},
+	))
+
+	first, err := m.DeriveNamespacedID("tenant-a")
+	if err != nil {
+		t.Fatalf("DeriveNamespacedID: %v", err)
+	}
+	second, err := m.DeriveNamespacedID("tenant-a")
+	if err != nil {
+		t.Fatalf("DeriveNamespacedID (second call): %v", err)
+	}
+	if first != second {
+		t.Errorf("DeriveNamespacedID not stable across calls: got %q then %q", first, second)
+	}
+}
+
+func TestDeriveNamespacedID_DistinctNamespacesDiverge(t *testing.T) {
+	m := NewManager(testConfig(t,
+		StaticSource{SourceName: factSystemUUID, Value: []byte("uuid-1")},
+	))
+
+	idA, err := m.DeriveNamespacedID("tenant-a")
+	if err != nil {
+		t.Fatalf("DeriveNamespacedID(tenant-a): %v", err)
+	}
+	idB, err := m.DeriveNamespacedID("tenant-b")
+	if err != nil {
+		t.Fatalf("DeriveNamespacedID(tenant-b): %v", err)
+	}
+	if idA == idB {
+		t.Errorf("distinct namespaces produced the same ID: %q", idA)
+	}
+}
+
+func TestVerifyDeviceIDContext_NamespaceRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	sources := []IdentitySource{StaticSource{SourceName: factSystemUUID, Value: []byte("uuid-1")}}
+
+	namespaced := NewManager(Config{StorageDir: dir, Sources: sources, Namespace: "tenant-a"})
+
+	first, err := namespaced.VerifyDeviceIDContext(context.Background())
+	if err != nil {
+		t.Fatalf("VerifyDeviceIDContext: %v", err)
+	}
+	second, err := namespaced.VerifyDeviceIDContext(context.Background())
+	if err != nil {
+		t.Fatalf("VerifyDeviceIDContext (second call): %v", err)
+	}
+	if first != second {
+		t.Errorf("VerifyDeviceIDContext with Config.Namespace not stable: got %q then %q", first, second)
+	}
+
+	// A plain (non-namespaced) Manager pointed at the same storage and
+	// sources should derive the identical namespaced ID, confirming
+	// Config.Namespace reroutes VerifyDeviceIDContext to the same
+	// derivation DeriveNamespacedID exposes directly.
+	root := NewManager(Config{StorageDir: dir, Sources: sources})
+	derived, err := root.DeriveNamespacedID("tenant-a")
+	if err != nil {
+		t.Fatalf("DeriveNamespacedID: %v", err)
+	}
+	if derived != first {
+		t.Errorf("Config.Namespace round trip mismatch: VerifyDeviceIDContext gave %q, DeriveNamespacedID gave %q", first, derived)
+	}
+}
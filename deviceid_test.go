@@ -0,0 +1,198 @@
+package deviceid
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testConfig(t *testing.T, facts ...IdentitySource) Config {
+	t.Helper()
+	return Config{
+		StorageDir:       t.TempDir(),
+		Sources:          facts,
+		SigningKeySource: StaticSource{SourceName: "board_serial", Value: []byte("test-board-serial")},
+	}
+}
+
+func TestVerifyDeviceIDFuzzy_ThresholdAccounting(t *testing.T) {
+	m := NewManager(testConfig(t,
+		StaticSource{SourceName: factSystemUUID, Value: []byte("uuid-1")},
+		StaticSource{SourceName: factDiskSerial, Value: []byte("disk-1")},
+		StaticSource{SourceName: factCPUModel, Value: []byte("cpu-1")},
+	))
+
+	storedID, _, err := m.VerifyDeviceIDFuzzy(2)
+	if err != nil {
+		t.Fatalf("initial VerifyDeviceIDFuzzy: %v", err)
+	}
+
+	// Same facts: everything should match.
+	id, result, err := m.VerifyDeviceIDFuzzy(2)
+	if err != nil {
+		t.Fatalf("VerifyDeviceIDFuzzy with unchanged facts: %v", err)
+	}
+	if id != storedID {
+		t.Errorf("device ID changed with unchanged facts: got %q, want %q", id, storedID)
+	}
+	if len(result.Matched) != 3 || len(result.Drifted) != 0 || len(result.Missing) != 0 {
+		t.Errorf("unexpected match result: %+v", result)
+	}
+
+	// Drift one fact: two matches still meet the threshold, should still be accepted.
+	m.config.Sources = []IdentitySource{
+		StaticSource{SourceName: factSystemUUID, Value: []byte("uuid-1")},
+		StaticSource{SourceName: factDiskSerial, Value: []byte("disk-CHANGED")},
+		StaticSource{SourceName: factCPUModel, Value: []byte("cpu-1")},
+	}
+	id, result, err = m.VerifyDeviceIDFuzzy(2)
+	if err != nil {
+		t.Fatalf("VerifyDeviceIDFuzzy with one drifted fact: %v", err)
+	}
+	if id != storedID {
+		t.Errorf("device ID should remain stable under fuzzy match: got %q, want %q", id, storedID)
+	}
+	if len(result.Matched) != 2 || len(result.Drifted) != 1 {
+		t.Errorf("unexpected match result after drift: %+v", result)
+	}
+
+	// Drift below threshold: should be rejected.
+	m.config.Sources = []IdentitySource{
+		StaticSource{SourceName: factSystemUUID, Value: []byte("uuid-CHANGED")},
+		StaticSource{SourceName: factDiskSerial, Value: []byte("disk-CHANGED")},
+		StaticSource{SourceName: factCPUModel, Value: []byte("cpu-1")},
+	}
+	if _, _, err := m.VerifyDeviceIDFuzzy(2); err == nil {
+		t.Fatal("expected error when fewer than threshold facts match")
+	}
+}
+
+func TestSignedDeviceID_TamperDetection(t *testing.T) {
+	m := NewManager(testConfig(t,
+		StaticSource{SourceName: factSystemUUID, Value: []byte("uuid-1")},
+	))
+
+	id, err := m.VerifySignedDeviceID()
+	if err != nil {
+		t.Fatalf("initial VerifySignedDeviceID: %v", err)
+	}
+
+	// Unmodified envelope verifies cleanly.
+	if got, err := m.VerifySignedDeviceID(); err != nil || got != id {
+		t.Fatalf("VerifySignedDeviceID on untouched envelope: got %q, err %v", got, err)
+	}
+
+	path, err := m.GetDeviceIDPath()
+	if err != nil {
+		t.Fatalf("GetDeviceIDPath: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read device ID file: %v", err)
+	}
+
+	tampered := string(raw) + "tamper"
+	if err := os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+		t.Fatalf("write tampered device ID file: %v", err)
+	}
+
+	if _, err := m.VerifySignedDeviceID(); !errors.Is(err, ErrTampered) {
+		t.Fatalf("VerifySignedDeviceID on tampered envelope: got err %v, want ErrTampered", err)
+	}
+}
+
+func TestVerifySignedDeviceID_MigratesLegacyPlainHex(t *testing.T) {
+	m := NewManager(testConfig(t,
+		StaticSource{SourceName: factSystemUUID, Value: []byte("uuid-1")},
+	))
+
+	legacyID, err := m.GenerateDeviceID()
+	if err != nil {
+		t.Fatalf("GenerateDeviceID: %v", err)
+	}
+	if err := m.SaveDeviceID(legacyID); err != nil {
+		t.Fatalf("SaveDeviceID: %v", err)
+	}
+
+	path, err := m.GetDeviceIDPath()
+	if err != nil {
+		t.Fatalf("GetDeviceIDPath: %v", err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read legacy device ID file: %v", err)
+	}
+	if _, ok := parseEnvelope(string(before)); ok {
+		t.Fatal("legacy fixture file unexpectedly parses as a signed envelope")
+	}
+
+	migratedID, err := m.VerifySignedDeviceID()
+	if err != nil {
+		t.Fatalf("VerifySignedDeviceID on legacy file: %v", err)
+	}
+	if migratedID != legacyID {
+		t.Errorf("migrated device ID changed: got %q, want %q", migratedID, legacyID)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read migrated device ID file: %v", err)
+	}
+	envelope, ok := parseEnvelope(string(after))
+	if !ok {
+		t.Fatal("device ID file was not migrated to a signed envelope")
+	}
+	if envelope.DeviceID != legacyID {
+		t.Errorf("migrated envelope device ID: got %q, want %q", envelope.DeviceID, legacyID)
+	}
+
+	// Re-verifying the now-signed file should succeed without further migration.
+	if got, err := m.VerifySignedDeviceID(); err != nil || got != legacyID {
+		t.Fatalf("VerifySignedDeviceID after migration: got %q, err %v", got, err)
+	}
+
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		t.Fatalf("storage directory missing: %v", err)
+	}
+}
+
+func TestIdentitySources_FallsBackToDefaultsWhenUnconfigured(t *testing.T) {
+	m := NewManager(Config{StorageDir: t.TempDir()})
+
+	sources := m.identitySources()
+	if len(sources) == 0 {
+		t.Fatal("identitySources returned none; expected the OS-appropriate built-in defaults")
+	}
+	for _, src := range sources {
+		if src.Name() == "" {
+			t.Errorf("default source %T returned an empty Name()", src)
+		}
+	}
+}
+
+func TestIdentitySources_PrefersConfiguredSourcesOverDefaults(t *testing.T) {
+	custom := []IdentitySource{StaticSource{SourceName: "custom", Value: []byte("v")}}
+	m := NewManager(Config{StorageDir: t.TempDir(), Sources: custom})
+
+	got := m.identitySources()
+	if len(got) != 1 || got[0].Name() != "custom" {
+		t.Fatalf("identitySources: got %+v, want the single configured custom source", got)
+	}
+}
+
+func TestStaticSource_CollectReturnsConfiguredValue(t *testing.T) {
+	src := StaticSource{SourceName: "fact", Value: []byte("value")}
+
+	if src.Name() != "fact" {
+		t.Errorf("Name(): got %q, want %q", src.Name(), "fact")
+	}
+	got, err := src.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("Collect: got %q, want %q", got, "value")
+	}
+}